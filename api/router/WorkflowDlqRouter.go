@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package router
+
+import (
+	"github.com/devtron-labs/devtron/api/restHandler"
+	"github.com/gorilla/mux"
+)
+
+type WorkflowDlqRouter interface {
+	InitWorkflowDlqRouter(router *mux.Router)
+}
+
+type WorkflowDlqRouterImpl struct {
+	dlqRestHandler restHandler.WorkflowDlqRestHandler
+}
+
+func NewWorkflowDlqRouterImpl(dlqRestHandler restHandler.WorkflowDlqRestHandler) *WorkflowDlqRouterImpl {
+	return &WorkflowDlqRouterImpl{dlqRestHandler: dlqRestHandler}
+}
+
+func (router *WorkflowDlqRouterImpl) InitWorkflowDlqRouter(workflowDlqRouter *mux.Router) {
+	workflowDlqRouter.Path("").
+		HandlerFunc(router.dlqRestHandler.ListDlqEntries).Methods("GET")
+	workflowDlqRouter.Path("/{id}").
+		HandlerFunc(router.dlqRestHandler.GetDlqEntry).Methods("GET")
+	workflowDlqRouter.Path("/{id}/replay").
+		HandlerFunc(router.dlqRestHandler.ReplayDlqEntry).Methods("POST")
+}
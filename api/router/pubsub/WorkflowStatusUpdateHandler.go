@@ -18,31 +18,48 @@
 package pubsub
 
 import (
+	"encoding/json"
+	"github.com/argoproj/argo/pkg/apis/workflow/v1alpha1"
 	"github.com/devtron-labs/devtron/api/bean"
 	client "github.com/devtron-labs/devtron/client/events"
-	"github.com/devtron-labs/devtron/client/pubsub"
+	"github.com/devtron-labs/devtron/internal/sql/repository"
 	"github.com/devtron-labs/devtron/internal/sql/repository/pipelineConfig"
 	"github.com/devtron-labs/devtron/pkg/pipeline"
+	"github.com/devtron-labs/devtron/pkg/pubsub/broker"
 	"github.com/devtron-labs/devtron/util/event"
-	"encoding/json"
-	"github.com/argoproj/argo/pkg/apis/workflow/v1alpha1"
-	"github.com/nats-io/stan"
 	"go.uber.org/zap"
 	"time"
 )
 
 type WorkflowStatusUpdateHandler interface {
 	Subscribe() error
+	// Healthz reports the health of every managed subscription (current state, last-message-
+	// received timestamp, and the last delivered message's key) so the API server's health
+	// endpoint can surface a degraded pubsub pipeline instead of silently dropping workflow
+	// updates. It does not report consumer lag: the broker-agnostic Subscription interface has
+	// no way to ask a backend for its current/durable sequence, and "sequence" itself isn't a
+	// comparable number on every backend (e.g. Google Pub/Sub's is an opaque message ID).
+	Healthz() []SubscriptionHealth
+	// Shutdown stops every managed subscription's background watcher and releases its broker
+	// resources. Call it from the API server's graceful shutdown path.
+	Shutdown()
 }
 
 type WorkflowStatusUpdateHandlerImpl struct {
-	logger               *zap.SugaredLogger
-	pubsubClient         *pubsub.PubSubClient
-	ciHandler            pipeline.CiHandler
-	cdHandler            pipeline.CdHandler
-	eventFactory         client.EventFactory
-	eventClient          client.EventClient
-	cdWorkflowRepository pipelineConfig.CdWorkflowRepository
+	logger                        *zap.SugaredLogger
+	broker                        broker.Broker
+	ackWait                       time.Duration
+	ciHandler                     pipeline.CiHandler
+	cdHandler                     pipeline.CdHandler
+	eventFactory                  client.EventFactory
+	eventClient                   client.EventClient
+	cdWorkflowRepository          pipelineConfig.CdWorkflowRepository
+	cdWorkflowScmMetaRepository   pipelineConfig.CdWorkflowScmMetaRepository
+	cdNotificationDedupRepository repository.CdNotificationDedupRepository
+	cloudEventFactory             client.CloudEventFactory
+	cloudEventSink                client.CloudEventSink
+	supervisors                   []*subscriptionSupervisor
+	retryGuard                    *messageRetryGuard
 }
 
 const workflowStatusUpdate = "WORKFLOW_STATUS_UPDATE"
@@ -53,16 +70,30 @@ const cdWorkflowStatusUpdate = "CD_WORKFLOW_STATUS_UPDATE"
 const cdWorkflowStatusUpdateGroup = "CD_WORKFLOW_STATUS_UPDATE_GROUP-1"
 const cdWorkflowStatusUpdateDurable = "CD_WORKFLOW_STATUS_UPDATE_DURABLE-1"
 
-func NewWorkflowStatusUpdateHandlerImpl(logger *zap.SugaredLogger, pubsubClient *pubsub.PubSubClient, ciHandler pipeline.CiHandler, cdHandler pipeline.CdHandler,
-	eventFactory client.EventFactory, eventClient client.EventClient, cdWorkflowRepository pipelineConfig.CdWorkflowRepository) *WorkflowStatusUpdateHandlerImpl {
+// subscriptionMaxRetries bounds how many times a subscriptionSupervisor retries before giving up
+// and reporting subscriptionStateFailed via Healthz(). 0 would mean unlimited; devtron prefers to
+// surface a persistently broken pubsub pipeline rather than retry forever in silence.
+const subscriptionMaxRetries = 20
+
+func NewWorkflowStatusUpdateHandlerImpl(logger *zap.SugaredLogger, pubsubBroker broker.Broker, ackWait time.Duration, ciHandler pipeline.CiHandler, cdHandler pipeline.CdHandler,
+	eventFactory client.EventFactory, eventClient client.EventClient, cdWorkflowRepository pipelineConfig.CdWorkflowRepository,
+	cloudEventFactory client.CloudEventFactory, cloudEventSink client.CloudEventSink,
+	messageAttemptRepository repository.WorkflowStatusMessageAttemptRepository, dlqRepository repository.WorkflowStatusDlqRepository,
+	cdWorkflowScmMetaRepository pipelineConfig.CdWorkflowScmMetaRepository, cdNotificationDedupRepository repository.CdNotificationDedupRepository) *WorkflowStatusUpdateHandlerImpl {
 	workflowStatusUpdateHandlerImpl := &WorkflowStatusUpdateHandlerImpl{
-		logger:               logger,
-		pubsubClient:         pubsubClient,
-		ciHandler:            ciHandler,
-		cdHandler:            cdHandler,
-		eventFactory:         eventFactory,
-		eventClient:          eventClient,
-		cdWorkflowRepository: cdWorkflowRepository,
+		logger:                        logger,
+		broker:                        pubsubBroker,
+		ackWait:                       ackWait,
+		ciHandler:                     ciHandler,
+		cdHandler:                     cdHandler,
+		eventFactory:                  eventFactory,
+		eventClient:                   eventClient,
+		cdWorkflowRepository:          cdWorkflowRepository,
+		cdWorkflowScmMetaRepository:   cdWorkflowScmMetaRepository,
+		cdNotificationDedupRepository: cdNotificationDedupRepository,
+		cloudEventFactory:             cloudEventFactory,
+		cloudEventSink:                cloudEventSink,
+		retryGuard:                    newMessageRetryGuard(logger, messageAttemptRepository, dlqRepository, pubsubBroker),
 	}
 	err := workflowStatusUpdateHandlerImpl.Subscribe()
 	if err != nil {
@@ -78,84 +109,174 @@ func NewWorkflowStatusUpdateHandlerImpl(logger *zap.SugaredLogger, pubsubClient
 }
 
 func (impl *WorkflowStatusUpdateHandlerImpl) Subscribe() error {
-	_, err := impl.pubsubClient.Conn.QueueSubscribe(workflowStatusUpdate, workflowStatusUpdateGroup, func(msg *stan.Msg) {
-		impl.logger.Debug("received wf update request")
-		defer msg.Ack()
-		wfStatus := v1alpha1.WorkflowStatus{}
-		err := json.Unmarshal([]byte(string(msg.Data)), &wfStatus)
-		if err != nil {
-			impl.logger.Errorw("error on wf status update", "err", err, "msg", string(msg.Data))
-			return
-		}
-		_, err = impl.ciHandler.UpdateWorkflow(wfStatus)
-		if err != nil {
-			impl.logger.Errorw("error on update workflow status", "err", err, "msg", string(msg.Data))
-			return
-		}
-	}, stan.DurableName(workflowStatusUpdateDurable), stan.StartWithLastReceived(), stan.AckWait(time.Duration(impl.pubsubClient.AckDuration)*time.Second), stan.SetManualAckMode(), stan.MaxInflight(1))
-
-	if err != nil {
-		impl.logger.Error("err", err)
-		return err
+	cfg := broker.SubscriptionConfig{
+		DurableName:           workflowStatusUpdateDurable,
+		QueueGroup:            workflowStatusUpdateGroup,
+		AckWait:               impl.ackWait,
+		MaxInflight:           1,
+		StartWithLastReceived: true,
 	}
+	var supervisor *subscriptionSupervisor
+	supervisor = newSubscriptionSupervisor(impl.logger, workflowStatusUpdateDurable, subscriptionMaxRetries, func() (broker.Subscription, error) {
+		return impl.broker.Subscribe(workflowStatusUpdate, cfg, func(msg broker.Message) {
+			impl.logger.Debug("received wf update request")
+			supervisor.onMessage(msg.Attributes()["sequence"])
+			impl.retryGuard.handle(workflowStatusUpdate, workflowStatusUpdateDlq, msg, func() error {
+				wfStatus := v1alpha1.WorkflowStatus{}
+				err := json.Unmarshal(msg.Data(), &wfStatus)
+				if err != nil {
+					impl.logger.Errorw("error on wf status update", "err", err, "msg", string(msg.Data()))
+					return err
+				}
+				_, err = impl.ciHandler.UpdateWorkflow(wfStatus)
+				if err != nil {
+					impl.logger.Errorw("error on update workflow status", "err", err, "msg", string(msg.Data()))
+					return err
+				}
+				return nil
+			})
+		})
+	})
+	supervisor.start()
+	impl.supervisors = append(impl.supervisors, supervisor)
 	return nil
 }
 
+// sendCloudEvent emits a CloudEvents v1.0 envelope for the given CD workflow transition to the
+// configured CloudEventSink. The sink is optional (nil when no downstream consumer is
+// configured), in which case this is a no-op.
+func (impl *WorkflowStatusUpdateHandlerImpl) sendCloudEvent(wfrId int, wfStatus v1alpha1.WorkflowStatus, workflowType bean.WorkflowType, pipelineId int, appId int, envId int, scm *client.SCMTriggerInfo) {
+	if impl.cloudEventFactory == nil || impl.cloudEventSink == nil {
+		return
+	}
+	cloudEvent := impl.cloudEventFactory.BuildCDWorkflowEvent(wfrId, wfStatus, workflowType, pipelineId, appId, envId, scm)
+	if err := impl.cloudEventSink.Send(cloudEvent); err != nil {
+		impl.logger.Errorw("error sending cloud event for cd workflow status", "err", err, "pipelineId", pipelineId)
+	}
+}
+
+// scmTriggerInfoForCdWorkflow reads back the SCM metadata captured when the CD workflow was
+// created, if any, along with the originating SCM-event timestamp used for de-duping stale
+// re-deliveries. It returns a nil SCMTriggerInfo and a zero time when the workflow wasn't
+// triggered by an SCM event or the metadata can't be found.
+func (impl *WorkflowStatusUpdateHandlerImpl) scmTriggerInfoForCdWorkflow(cdWorkflowId int) (*client.SCMTriggerInfo, time.Time) {
+	meta, err := impl.cdWorkflowScmMetaRepository.FindByCdWorkflowId(cdWorkflowId)
+	if err != nil || meta == nil || meta.CommitSha == "" {
+		return nil, time.Time{}
+	}
+	return &client.SCMTriggerInfo{CommitSha: meta.CommitSha, PrRef: meta.Ref}, meta.ScmEventAt
+}
+
+// shouldNotify applies the (pipelineId, workflowType, pr-updated-at) de-dup guard: a re-run
+// triggered by a stale/re-delivered PR webhook event must not spam notifiers a second time for the
+// same PR update. workflowType is part of the key because the pre and post stages of one
+// SCM-triggered run share the same scmEventAt but are each their own distinct notification.
+// shouldNotify always returns true when there's no SCM metadata to de-dup against.
+func (impl *WorkflowStatusUpdateHandlerImpl) shouldNotify(pipelineId int, workflowType bean.WorkflowType, scmEventAt time.Time) bool {
+	if scmEventAt.IsZero() {
+		return true
+	}
+	dedup, err := impl.cdNotificationDedupRepository.FindByPipelineIdAndWorkflowType(pipelineId, workflowType)
+	if err == nil && dedup != nil && !dedup.PrUpdatedAt.Before(scmEventAt) {
+		impl.logger.Debugw("skipping notification for stale pr event", "pipelineId", pipelineId, "workflowType", workflowType, "scmEventAt", scmEventAt, "lastNotified", dedup.PrUpdatedAt)
+		return false
+	}
+	if err := impl.cdNotificationDedupRepository.Upsert(&repository.CdNotificationDedup{PipelineId: pipelineId, WorkflowType: workflowType, PrUpdatedAt: scmEventAt, NotifiedOn: time.Now()}); err != nil {
+		impl.logger.Errorw("error updating notification dedup record", "pipelineId", pipelineId, "workflowType", workflowType, "err", err)
+	}
+	return true
+}
+
 func (impl *WorkflowStatusUpdateHandlerImpl) SubscribeCD() error {
-	_, err := impl.pubsubClient.Conn.QueueSubscribe(cdWorkflowStatusUpdate, cdWorkflowStatusUpdateGroup, func(msg *stan.Msg) {
-		impl.logger.Debug("received cd wf update request")
-		defer msg.Ack()
-		wfStatus := v1alpha1.WorkflowStatus{}
-		err := json.Unmarshal([]byte(string(msg.Data)), &wfStatus)
-		if err != nil {
-			impl.logger.Error("err", err)
-			return
-		}
-		impl.logger.Debugw("received cd wf update request body", "body", wfStatus)
-		wfrId, wfrStatus, err := impl.cdHandler.UpdateWorkflow(wfStatus)
-		impl.logger.Debug(wfrId)
-		if err != nil {
-			impl.logger.Error("err", err)
-			return
-		}
-
-		wfr, err := impl.cdWorkflowRepository.FindWorkflowRunnerById(wfrId)
-		if err != nil {
-			impl.logger.Errorw("could not get wf runner", "err", err)
-			return
-		}
-		if wfrStatus == string(v1alpha1.NodeSucceeded) ||
-			wfrStatus == string(v1alpha1.NodeFailed) || wfrStatus == string(v1alpha1.NodeError) {
-			eventType := util.EventType(0)
-			if wfrStatus == string(v1alpha1.NodeSucceeded) {
-				eventType = util.Success
-			} else if wfrStatus == string(v1alpha1.NodeFailed) || wfrStatus == string(v1alpha1.NodeError) {
-				eventType = util.Fail
-			}
-			if wfr.WorkflowType == bean.CD_WORKFLOW_TYPE_PRE {
-				event := impl.eventFactory.Build(eventType, &wfr.CdWorkflow.PipelineId, wfr.CdWorkflow.Pipeline.AppId, &wfr.CdWorkflow.Pipeline.EnvironmentId, util.CD)
-				impl.logger.Debugw("event pre stage", "event", event)
-				event = impl.eventFactory.BuildExtraCDData(event, wfr, 0, bean.CD_WORKFLOW_TYPE_PRE)
-				_, evtErr := impl.eventClient.WriteEvent(event)
-				if evtErr != nil {
-					impl.logger.Errorw("CD stage post fail or success event unable to sent", "error", evtErr)
+	cfg := broker.SubscriptionConfig{
+		DurableName:           cdWorkflowStatusUpdateDurable,
+		QueueGroup:            cdWorkflowStatusUpdateGroup,
+		AckWait:               impl.ackWait,
+		MaxInflight:           1,
+		StartWithLastReceived: true,
+	}
+	var supervisor *subscriptionSupervisor
+	supervisor = newSubscriptionSupervisor(impl.logger, cdWorkflowStatusUpdateDurable, subscriptionMaxRetries, func() (broker.Subscription, error) {
+		return impl.broker.Subscribe(cdWorkflowStatusUpdate, cfg, func(msg broker.Message) {
+			impl.logger.Debug("received cd wf update request")
+			supervisor.onMessage(msg.Attributes()["sequence"])
+			impl.retryGuard.handle(cdWorkflowStatusUpdate, cdWorkflowStatusUpdateDlq, msg, func() error {
+				wfStatus := v1alpha1.WorkflowStatus{}
+				err := json.Unmarshal(msg.Data(), &wfStatus)
+				if err != nil {
+					impl.logger.Error("err", err)
+					return err
+				}
+				impl.logger.Debugw("received cd wf update request body", "body", wfStatus)
+				wfrId, wfrStatus, err := impl.cdHandler.UpdateWorkflow(wfStatus)
+				impl.logger.Debug(wfrId)
+				if err != nil {
+					impl.logger.Error("err", err)
+					return err
 				}
 
-			} else if wfr.WorkflowType == bean.CD_WORKFLOW_TYPE_POST {
-				event := impl.eventFactory.Build(eventType, &wfr.CdWorkflow.PipelineId, wfr.CdWorkflow.Pipeline.AppId, &wfr.CdWorkflow.Pipeline.EnvironmentId, util.CD)
-				impl.logger.Debugw("event post stage", "event", event)
-				event = impl.eventFactory.BuildExtraCDData(event, wfr, 0, bean.CD_WORKFLOW_TYPE_POST)
-				_, evtErr := impl.eventClient.WriteEvent(event)
-				if evtErr != nil {
-					impl.logger.Errorw("CD stage post fail or success event not sent", "error", evtErr)
+				wfr, err := impl.cdWorkflowRepository.FindWorkflowRunnerById(wfrId)
+				if err != nil {
+					impl.logger.Errorw("could not get wf runner", "err", err)
+					return err
 				}
-			}
-		}
-	}, stan.DurableName(cdWorkflowStatusUpdateDurable), stan.StartWithLastReceived(), stan.AckWait(time.Duration(impl.pubsubClient.AckDuration)*time.Second), stan.SetManualAckMode(), stan.MaxInflight(1))
+				if wfrStatus == string(v1alpha1.NodeSucceeded) ||
+					wfrStatus == string(v1alpha1.NodeFailed) || wfrStatus == string(v1alpha1.NodeError) {
+					eventType := util.EventType(0)
+					if wfrStatus == string(v1alpha1.NodeSucceeded) {
+						eventType = util.Success
+					} else if wfrStatus == string(v1alpha1.NodeFailed) || wfrStatus == string(v1alpha1.NodeError) {
+						eventType = util.Fail
+					}
+					scm, scmEventAt := impl.scmTriggerInfoForCdWorkflow(wfr.CdWorkflowId)
+					notify := impl.shouldNotify(wfr.CdWorkflow.PipelineId, wfr.WorkflowType, scmEventAt)
+					if wfr.WorkflowType == bean.CD_WORKFLOW_TYPE_PRE {
+						event := impl.eventFactory.Build(eventType, &wfr.CdWorkflow.PipelineId, wfr.CdWorkflow.Pipeline.AppId, &wfr.CdWorkflow.Pipeline.EnvironmentId, util.CD)
+						impl.logger.Debugw("event pre stage", "event", event)
+						event = impl.eventFactory.BuildExtraCDData(event, wfr, 0, bean.CD_WORKFLOW_TYPE_PRE, scm)
+						if notify {
+							_, evtErr := impl.eventClient.WriteEvent(event)
+							if evtErr != nil {
+								impl.logger.Errorw("CD stage post fail or success event unable to sent", "error", evtErr)
+							}
+							impl.sendCloudEvent(wfrId, wfStatus, bean.CD_WORKFLOW_TYPE_PRE, wfr.CdWorkflow.PipelineId, wfr.CdWorkflow.Pipeline.AppId, wfr.CdWorkflow.Pipeline.EnvironmentId, scm)
+						}
 
-	if err != nil {
-		impl.logger.Error("err", err)
-		return err
-	}
+					} else if wfr.WorkflowType == bean.CD_WORKFLOW_TYPE_POST {
+						event := impl.eventFactory.Build(eventType, &wfr.CdWorkflow.PipelineId, wfr.CdWorkflow.Pipeline.AppId, &wfr.CdWorkflow.Pipeline.EnvironmentId, util.CD)
+						impl.logger.Debugw("event post stage", "event", event)
+						event = impl.eventFactory.BuildExtraCDData(event, wfr, 0, bean.CD_WORKFLOW_TYPE_POST, scm)
+						if notify {
+							_, evtErr := impl.eventClient.WriteEvent(event)
+							if evtErr != nil {
+								impl.logger.Errorw("CD stage post fail or success event not sent", "error", evtErr)
+							}
+							impl.sendCloudEvent(wfrId, wfStatus, bean.CD_WORKFLOW_TYPE_POST, wfr.CdWorkflow.PipelineId, wfr.CdWorkflow.Pipeline.AppId, wfr.CdWorkflow.Pipeline.EnvironmentId, scm)
+						}
+					}
+				}
+				return nil
+			})
+		})
+	})
+	supervisor.start()
+	impl.supervisors = append(impl.supervisors, supervisor)
 	return nil
-}
\ No newline at end of file
+}
+
+// Healthz reports the current health of every subscription this handler manages.
+func (impl *WorkflowStatusUpdateHandlerImpl) Healthz() []SubscriptionHealth {
+	health := make([]SubscriptionHealth, 0, len(impl.supervisors))
+	for _, supervisor := range impl.supervisors {
+		health = append(health, supervisor.health())
+	}
+	return health
+}
+
+// Shutdown stops every managed subscription's background watcher and releases its broker
+// resources (e.g. the Kafka reader's connection, or the Google Pub/Sub receive loop's context).
+func (impl *WorkflowStatusUpdateHandlerImpl) Shutdown() {
+	for _, supervisor := range impl.supervisors {
+		supervisor.stop()
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package pubsub
+
+import (
+	"time"
+
+	"github.com/devtron-labs/devtron/internal/sql/repository"
+	"github.com/devtron-labs/devtron/pkg/pubsub/broker"
+	"go.uber.org/zap"
+)
+
+// messageRetryMaxAttempts bounds how many times a poison message (bad payload, transient DB
+// outage, ...) is redelivered before it is diverted to the subject's dead-letter queue instead of
+// being retried forever or silently Acked and dropped.
+const messageRetryMaxAttempts = 5
+
+const workflowStatusUpdateDlq = "WORKFLOW_STATUS_UPDATE_DLQ"
+const cdWorkflowStatusUpdateDlq = "CD_WORKFLOW_STATUS_UPDATE_DLQ"
+
+// messageRetryGuard tracks per-message attempt counts across redeliveries and, once a message
+// reaches messageRetryMaxAttempts failed attempts, republishes it to a dead-letter subject with metadata about
+// the original subject, first-seen time, last error, and attempt count. A message is only Acked
+// after a successful handle or a successful DLQ publish; on transient failures it is left unacked
+// so the broker redelivers it after its ack-wait window.
+type messageRetryGuard struct {
+	logger            *zap.SugaredLogger
+	attemptRepository repository.WorkflowStatusMessageAttemptRepository
+	dlqRepository     repository.WorkflowStatusDlqRepository
+	publisher         broker.Publisher
+}
+
+func newMessageRetryGuard(logger *zap.SugaredLogger, attemptRepository repository.WorkflowStatusMessageAttemptRepository,
+	dlqRepository repository.WorkflowStatusDlqRepository, publisher broker.Publisher) *messageRetryGuard {
+	return &messageRetryGuard{
+		logger:            logger,
+		attemptRepository: attemptRepository,
+		dlqRepository:     dlqRepository,
+		publisher:         publisher,
+	}
+}
+
+// handle runs handleFn for msg and Acks it only on success or successful DLQ hand-off. subject is
+// the original subscription subject and dlqSubject is where exhausted messages are republished.
+func (guard *messageRetryGuard) handle(subject string, dlqSubject string, msg broker.Message, handleFn func() error) {
+	msgKey := msg.Attributes()["sequence"]
+	handleErr := handleFn()
+	if handleErr == nil {
+		msg.Ack()
+		if err := guard.attemptRepository.Delete(subject, msgKey); err != nil {
+			guard.logger.Errorw("error clearing message attempt record", "subject", subject, "msgKey", msgKey, "err", err)
+		}
+		return
+	}
+
+	attempt, err := guard.attemptRepository.FindByMsgKey(subject, msgKey)
+	if err != nil || attempt == nil {
+		attempt = &repository.WorkflowStatusMessageAttempt{Subject: subject, MsgKey: msgKey, FirstSeen: time.Now()}
+	}
+	attempt.Attempts++
+	attempt.LastError = handleErr.Error()
+	attempt.UpdatedOn = time.Now()
+	if err := guard.attemptRepository.Save(attempt); err != nil {
+		guard.logger.Errorw("error saving message attempt record", "subject", subject, "msgKey", msgKey, "err", err)
+	}
+
+	if attempt.Attempts < messageRetryMaxAttempts {
+		guard.logger.Warnw("transient error handling message, leaving unacked for redelivery", "subject", subject, "msgKey", msgKey, "attempts", attempt.Attempts, "err", handleErr)
+		return
+	}
+
+	guard.logger.Errorw("message exceeded max attempts, sending to dlq", "subject", subject, "msgKey", msgKey, "attempts", attempt.Attempts, "err", handleErr)
+	if err := guard.publishToDlq(subject, dlqSubject, msg.Data(), attempt); err != nil {
+		guard.logger.Errorw("error publishing message to dlq, leaving unacked for redelivery", "subject", subject, "msgKey", msgKey, "err", err)
+		return
+	}
+	msg.Ack()
+	if err := guard.attemptRepository.Delete(subject, msgKey); err != nil {
+		guard.logger.Errorw("error clearing message attempt record after dlq publish", "subject", subject, "msgKey", msgKey, "err", err)
+	}
+}
+
+func (guard *messageRetryGuard) publishToDlq(subject string, dlqSubject string, payload []byte, attempt *repository.WorkflowStatusMessageAttempt) error {
+	entry := &repository.WorkflowStatusDlqEntry{
+		OriginalSubject: subject,
+		DlqSubject:      dlqSubject,
+		Payload:         string(payload),
+		Attempts:        attempt.Attempts,
+		LastError:       attempt.LastError,
+		FirstSeen:       attempt.FirstSeen,
+	}
+	if err := guard.dlqRepository.Save(entry); err != nil {
+		return err
+	}
+	return guard.publisher.Publish(dlqSubject, payload)
+}
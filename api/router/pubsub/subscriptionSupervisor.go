@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package pubsub
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/devtron-labs/devtron/pkg/pubsub/broker"
+	"go.uber.org/zap"
+)
+
+type subscriptionState string
+
+const (
+	subscriptionStateHealthy      subscriptionState = "HEALTHY"
+	subscriptionStateReconnecting subscriptionState = "RECONNECTING"
+	subscriptionStateFailed       subscriptionState = "FAILED"
+)
+
+const (
+	subscriptionBackoffBase = 1 * time.Second
+	subscriptionBackoffCap  = 30 * time.Second
+	subscriptionWatchPeriod = 5 * time.Second
+)
+
+// SubscriptionHealth is the point-in-time health of a single broker subscription, surfaced via
+// WorkflowStatusUpdateHandler.Healthz() for the API server's health endpoint.
+type SubscriptionHealth struct {
+	Name             string    `json:"name"`
+	State            string    `json:"state"`
+	LastMessageAt    time.Time `json:"lastMessageAt"`
+	LastDeliveredKey string    `json:"lastDeliveredKey"`
+}
+
+// subscriptionSupervisor owns one broker subscription and keeps it alive: if the underlying
+// connection drops or the broker closes the subscription, it re-subscribes with exponential
+// backoff instead of silently dropping workflow updates. It is broker-agnostic so the same
+// supervisor works whether the subscription came from STAN, JetStream, Kafka, or Google Pub/Sub.
+type subscriptionSupervisor struct {
+	logger        *zap.SugaredLogger
+	name          string
+	subscribeFunc func() (broker.Subscription, error)
+	maxRetries    int
+
+	mu               sync.Mutex
+	sub              broker.Subscription
+	state            subscriptionState
+	lastMessageAt    time.Time
+	lastDeliveredKey string
+
+	stopCh chan struct{}
+}
+
+// newSubscriptionSupervisor creates a supervisor for a named subscription. subscribeFunc should
+// perform the actual broker.Subscribe call and return the resulting handle. maxRetries <= 0 means
+// retry forever.
+func newSubscriptionSupervisor(logger *zap.SugaredLogger, name string, maxRetries int, subscribeFunc func() (broker.Subscription, error)) *subscriptionSupervisor {
+	return &subscriptionSupervisor{
+		logger:        logger,
+		name:          name,
+		subscribeFunc: subscribeFunc,
+		maxRetries:    maxRetries,
+		state:         subscriptionStateFailed,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// start kicks off the initial subscription establishment and the background watcher
+// asynchronously. With subscriptionMaxRetries retries and a 30s-capped backoff, establishing the
+// very first subscription can take minutes if the broker is slow to come up; running that
+// synchronously would block whatever called Subscribe()/SubscribeCD(), which in this codebase is
+// the handler's own constructor. Callers should use Healthz() to observe whether the subscription
+// ever came up rather than relying on a start() error.
+func (s *subscriptionSupervisor) start() {
+	go func() {
+		sub, err := s.establishWithBackoff()
+		if err != nil {
+			s.logger.Errorw("giving up establishing subscription", "name", s.name, "err", err)
+			s.mu.Lock()
+			s.state = subscriptionStateFailed
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Lock()
+		s.sub = sub
+		s.state = subscriptionStateHealthy
+		s.mu.Unlock()
+		s.watch()
+	}()
+}
+
+func (s *subscriptionSupervisor) watch() {
+	ticker := time.NewTicker(subscriptionWatchPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			sub := s.sub
+			s.mu.Unlock()
+			if sub != nil && sub.IsValid() {
+				continue
+			}
+			s.logger.Warnw("subscription lost, re-establishing", "name", s.name)
+			s.mu.Lock()
+			s.state = subscriptionStateReconnecting
+			s.mu.Unlock()
+			// sub is already dead on the broker side, but Unsubscribe still needs to run so the
+			// adapter releases whatever it's holding locally (e.g. the Kafka reader's connection,
+			// or the Google Pub/Sub receive loop's context) instead of leaking it on every
+			// reconnect cycle.
+			if sub != nil {
+				if err := sub.Unsubscribe(); err != nil {
+					s.logger.Warnw("error releasing stale subscription", "name", s.name, "err", err)
+				}
+			}
+			newSub, err := s.establishWithBackoff()
+			if err != nil {
+				s.logger.Errorw("giving up re-establishing subscription", "name", s.name, "err", err)
+				s.mu.Lock()
+				s.state = subscriptionStateFailed
+				s.mu.Unlock()
+				return
+			}
+			s.mu.Lock()
+			s.sub = newSub
+			s.state = subscriptionStateHealthy
+			s.mu.Unlock()
+		}
+	}
+}
+
+// establishWithBackoff retries subscribeFunc with exponential backoff (1s -> 30s cap) plus
+// jitter, up to maxRetries attempts (unlimited if maxRetries <= 0).
+func (s *subscriptionSupervisor) establishWithBackoff() (broker.Subscription, error) {
+	backoff := subscriptionBackoffBase
+	var lastErr error
+	for attempt := 1; s.maxRetries <= 0 || attempt <= s.maxRetries; attempt++ {
+		sub, err := s.subscribeFunc()
+		if err == nil {
+			return sub, nil
+		}
+		lastErr = err
+		s.logger.Errorw("subscribe attempt failed", "name", s.name, "attempt", attempt, "err", err)
+		jitter := time.Duration(rand.Int63n(int64(backoff) / 2))
+		time.Sleep(backoff + jitter)
+		backoff *= 2
+		if backoff > subscriptionBackoffCap {
+			backoff = subscriptionBackoffCap
+		}
+	}
+	return nil, lastErr
+}
+
+// onMessage records that a message was just delivered, for Healthz() staleness reporting.
+func (s *subscriptionSupervisor) onMessage(deliveredKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastMessageAt = time.Now()
+	s.lastDeliveredKey = deliveredKey
+}
+
+func (s *subscriptionSupervisor) health() SubscriptionHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SubscriptionHealth{
+		Name:             s.name,
+		State:            string(s.state),
+		LastMessageAt:    s.lastMessageAt,
+		LastDeliveredKey: s.lastDeliveredKey,
+	}
+}
+
+// stop ends the background watcher and releases the current subscription's broker resources. It
+// is called from WorkflowStatusUpdateHandlerImpl.Shutdown().
+func (s *subscriptionSupervisor) stop() {
+	close(s.stopCh)
+	s.mu.Lock()
+	sub := s.sub
+	s.mu.Unlock()
+	if sub != nil {
+		if err := sub.Unsubscribe(); err != nil {
+			s.logger.Warnw("error releasing subscription on shutdown", "name", s.name, "err", err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/argoproj/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/devtron-labs/devtron/api/bean"
+)
+
+// CloudEventSpecVersion is the CloudEvents specification version this package emits.
+const CloudEventSpecVersion = "1.0"
+
+const cloudEventDataContentType = "application/json"
+
+// CloudEvent is a CloudEvents v1.0 envelope, structured JSON mode
+// (https://github.com/cloudevents/spec/blob/v1.0/spec.md).
+type CloudEvent struct {
+	SpecVersion     string            `json:"specversion"`
+	Type            string            `json:"type"`
+	Source          string            `json:"source"`
+	Id              string            `json:"id"`
+	Time            time.Time         `json:"time"`
+	DataContentType string            `json:"datacontenttype"`
+	Data            CloudEventData    `json:"data"`
+	Extensions      map[string]string `json:"-"`
+}
+
+// MarshalJSON renders the envelope per the CloudEvents JSON format spec
+// (https://github.com/cloudevents/spec/blob/v1.0/json-format.md#3-envelope), where extension
+// attributes are inlined as top-level members alongside the core ones rather than nested under a
+// key of their own. Without this, the struct tag above (needed so Extensions isn't itself treated
+// as a context attribute) would make json.Marshal silently drop them.
+func (e CloudEvent) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(e.Extensions)+7)
+	for k, v := range e.Extensions {
+		fields[k] = v
+	}
+	fields["specversion"] = e.SpecVersion
+	fields["type"] = e.Type
+	fields["source"] = e.Source
+	fields["id"] = e.Id
+	fields["time"] = e.Time
+	fields["datacontenttype"] = e.DataContentType
+	fields["data"] = e.Data
+	return json.Marshal(fields)
+}
+
+// CloudEventData is the `data` payload of a workflow lifecycle CloudEvent.
+type CloudEventData struct {
+	AppId         int    `json:"appId"`
+	PipelineId    int    `json:"pipelineId"`
+	EnvironmentId int    `json:"environmentId"`
+	WorkflowType  string `json:"workflowType"`
+	Phase         string `json:"phase"`
+	Message       string `json:"message,omitempty"`
+}
+
+// SCMTriggerInfo carries the originating SCM commit/PR, when the workflow was triggered by
+// an SCM webhook event, so it can be attached to the CloudEvent as extension attributes.
+type SCMTriggerInfo struct {
+	CommitSha string
+	PrRef     string
+}
+
+// CloudEventFactory builds CloudEvents v1.0 envelopes for CD workflow lifecycle transitions.
+type CloudEventFactory interface {
+	BuildCDWorkflowEvent(wfrId int, wfStatus v1alpha1.WorkflowStatus, workflowType bean.WorkflowType, pipelineId int, appId int, envId int, scm *SCMTriggerInfo) CloudEvent
+}
+
+type CloudEventFactoryImpl struct {
+}
+
+func NewCloudEventFactoryImpl() *CloudEventFactoryImpl {
+	return &CloudEventFactoryImpl{}
+}
+
+// BuildCDWorkflowEvent maps a terminal Argo node phase (Succeeded/Failed/Error) for the given
+// CD workflow stage (pre/post/deploy) to a spec-compliant CloudEvent. The `type` attribute is
+// stable across deploys, e.g. "devtron.workflow.cd.pre.succeeded.v1". `id` is derived from wfrId,
+// the CdWorkflowRunner's own id, so every distinct run gets a distinct event id even when the
+// pipeline, stage, and phase are identical across runs. When scm is non-nil, the triggering
+// commit/PR ref are attached as CloudEvents extension attributes.
+func (impl *CloudEventFactoryImpl) BuildCDWorkflowEvent(wfrId int, wfStatus v1alpha1.WorkflowStatus, workflowType bean.WorkflowType, pipelineId int, appId int, envId int, scm *SCMTriggerInfo) CloudEvent {
+	stage := cdStageName(workflowType)
+	outcome := cdOutcomeName(wfStatus.Phase)
+	eventTime := time.Now()
+	if wfStatus.FinishedAt.Time.Unix() > 0 {
+		eventTime = wfStatus.FinishedAt.Time
+	}
+	event := CloudEvent{
+		SpecVersion:     CloudEventSpecVersion,
+		Type:            fmt.Sprintf("devtron.workflow.cd.%s.%s.v1", stage, outcome),
+		Source:          fmt.Sprintf("%d/%d/%d", appId, pipelineId, envId),
+		Id:              fmt.Sprintf("%d-%s", wfrId, stage),
+		Time:            eventTime,
+		DataContentType: cloudEventDataContentType,
+		Data: CloudEventData{
+			AppId:         appId,
+			PipelineId:    pipelineId,
+			EnvironmentId: envId,
+			WorkflowType:  stage,
+			Phase:         string(wfStatus.Phase),
+			Message:       wfStatus.Message,
+		},
+	}
+	if scm != nil {
+		event.Extensions = map[string]string{
+			"scmcommitsha": scm.CommitSha,
+			"scmprref":     scm.PrRef,
+		}
+	}
+	return event
+}
+
+func cdStageName(workflowType bean.WorkflowType) string {
+	switch workflowType {
+	case bean.CD_WORKFLOW_TYPE_PRE:
+		return "pre"
+	case bean.CD_WORKFLOW_TYPE_POST:
+		return "post"
+	default:
+		return "deploy"
+	}
+}
+
+func cdOutcomeName(phase v1alpha1.NodePhase) string {
+	switch phase {
+	case v1alpha1.NodeSucceeded:
+		return "succeeded"
+	case v1alpha1.NodeFailed:
+		return "failed"
+	case v1alpha1.NodeError:
+		return "errored"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/devtron-labs/devtron/client/pubsub"
+	"go.uber.org/zap"
+)
+
+// CloudEventSink publishes a CloudEvent to a downstream consumer (Knative broker, Argo Events
+// source, generic webhook, ...) without the consumer needing to know Devtron's internal event
+// schema.
+type CloudEventSink interface {
+	Send(event CloudEvent) error
+}
+
+// CloudEventContentMode selects the HTTP binding used by HTTPCloudEventSink.
+type CloudEventContentMode string
+
+const (
+	CloudEventContentModeStructured CloudEventContentMode = "structured"
+	CloudEventContentModeBinary     CloudEventContentMode = "binary"
+)
+
+// HTTPCloudEventSink sends CloudEvents over HTTP using either the structured (whole envelope as
+// JSON body) or binary (attributes as ce-* headers, data as body) content mode.
+// https://github.com/cloudevents/spec/blob/v1.0/http-protocol-binding.md
+type HTTPCloudEventSink struct {
+	logger      *zap.SugaredLogger
+	url         string
+	contentMode CloudEventContentMode
+	client      *http.Client
+}
+
+func NewHTTPCloudEventSink(logger *zap.SugaredLogger, url string, contentMode CloudEventContentMode) *HTTPCloudEventSink {
+	return &HTTPCloudEventSink{
+		logger:      logger,
+		url:         url,
+		contentMode: contentMode,
+		client:      http.DefaultClient,
+	}
+}
+
+func (sink *HTTPCloudEventSink) Send(event CloudEvent) error {
+	var req *http.Request
+	var err error
+	if sink.contentMode == CloudEventContentModeBinary {
+		req, err = sink.buildBinaryRequest(event)
+	} else {
+		req, err = sink.buildStructuredRequest(event)
+	}
+	if err != nil {
+		return err
+	}
+	resp, err := sink.client.Do(req)
+	if err != nil {
+		sink.logger.Errorw("error sending cloud event", "url", sink.url, "err", err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("cloud event sink %s returned status %d", sink.url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (sink *HTTPCloudEventSink) buildStructuredRequest(event CloudEvent) (*http.Request, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, sink.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+	return req, nil
+}
+
+func (sink *HTTPCloudEventSink) buildBinaryRequest(event CloudEvent) (*http.Request, error) {
+	body, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, sink.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", event.DataContentType)
+	req.Header.Set("ce-specversion", event.SpecVersion)
+	req.Header.Set("ce-type", event.Type)
+	req.Header.Set("ce-source", event.Source)
+	req.Header.Set("ce-id", event.Id)
+	req.Header.Set("ce-time", event.Time.Format(timeFormatRFC3339))
+	for k, v := range event.Extensions {
+		req.Header.Set("ce-"+k, v)
+	}
+	return req, nil
+}
+
+const timeFormatRFC3339 = "2006-01-02T15:04:05Z07:00"
+
+// NATSCloudEventSink publishes the structured-mode JSON encoding of a CloudEvent onto a NATS
+// Streaming subject, for consumers such as Argo Events' NATS event source.
+type NATSCloudEventSink struct {
+	logger       *zap.SugaredLogger
+	pubsubClient *pubsub.PubSubClient
+	subject      string
+}
+
+func NewNATSCloudEventSink(logger *zap.SugaredLogger, pubsubClient *pubsub.PubSubClient, subject string) *NATSCloudEventSink {
+	return &NATSCloudEventSink{
+		logger:       logger,
+		pubsubClient: pubsubClient,
+		subject:      subject,
+	}
+}
+
+func (sink *NATSCloudEventSink) Send(event CloudEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	err = sink.pubsubClient.Conn.Publish(sink.subject, body)
+	if err != nil {
+		sink.logger.Errorw("error publishing cloud event", "subject", sink.subject, "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package repository
+
+import (
+	"time"
+
+	"github.com/devtron-labs/devtron/api/bean"
+	"github.com/go-pg/pg"
+)
+
+// CdNotificationDedup remembers the most recent SCM-event timestamp a CD pipeline's stage was
+// already notified for, so a stale PR webhook re-delivery that re-triggers the same CD workflow
+// doesn't spam Slack/email/webhook notifiers a second time. It is keyed on (pipelineId,
+// workflowType) rather than pipelineId alone because the pre and post stages of the same
+// SCM-triggered run share one scmEventAt: without the stage in the key, recording the pre-stage
+// notification would make the post-stage's own, distinct notification look like a stale repeat.
+type CdNotificationDedup struct {
+	tableName    struct{}          `sql:"cd_notification_dedup" pg:",discard_unknown_columns"`
+	PipelineId   int               `sql:"pipeline_id,pk"`
+	WorkflowType bean.WorkflowType `sql:"workflow_type,pk"`
+	PrUpdatedAt  time.Time         `sql:"pr_updated_at,notnull"`
+	NotifiedOn   time.Time         `sql:"notified_on,notnull"`
+}
+
+type CdNotificationDedupRepository interface {
+	FindByPipelineIdAndWorkflowType(pipelineId int, workflowType bean.WorkflowType) (*CdNotificationDedup, error)
+	Upsert(dedup *CdNotificationDedup) error
+}
+
+type CdNotificationDedupRepositoryImpl struct {
+	dbConnection *pg.DB
+}
+
+func NewCdNotificationDedupRepositoryImpl(dbConnection *pg.DB) *CdNotificationDedupRepositoryImpl {
+	return &CdNotificationDedupRepositoryImpl{dbConnection: dbConnection}
+}
+
+func (impl *CdNotificationDedupRepositoryImpl) FindByPipelineIdAndWorkflowType(pipelineId int, workflowType bean.WorkflowType) (*CdNotificationDedup, error) {
+	dedup := &CdNotificationDedup{PipelineId: pipelineId, WorkflowType: workflowType}
+	err := impl.dbConnection.Model(dedup).WherePK().Select()
+	return dedup, err
+}
+
+func (impl *CdNotificationDedupRepositoryImpl) Upsert(dedup *CdNotificationDedup) error {
+	_, err := impl.dbConnection.Model(dedup).
+		OnConflict("(pipeline_id, workflow_type) DO UPDATE").
+		Set("pr_updated_at = EXCLUDED.pr_updated_at, notified_on = EXCLUDED.notified_on").
+		Insert()
+	return err
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package repository
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+// WorkflowStatusDlqEntry is a workflow status message that exhausted its bounded retry budget and
+// was diverted to a dead-letter subject instead of being dropped or retried forever.
+type WorkflowStatusDlqEntry struct {
+	tableName       struct{}  `sql:"wf_status_dlq_entries" pg:",discard_unknown_columns"`
+	Id              int       `sql:"id,pk"`
+	OriginalSubject string    `sql:"original_subject,notnull"`
+	DlqSubject      string    `sql:"dlq_subject,notnull"`
+	Payload         string    `sql:"payload,notnull"`
+	Attempts        int       `sql:"attempts,notnull"`
+	LastError       string    `sql:"last_error"`
+	FirstSeen       time.Time `sql:"first_seen,notnull"`
+	Replayed        bool      `sql:"replayed,notnull"`
+	ReplayedOn      time.Time `sql:"replayed_on"`
+}
+
+type WorkflowStatusDlqRepository interface {
+	Save(entry *WorkflowStatusDlqEntry) error
+	FindAll() ([]*WorkflowStatusDlqEntry, error)
+	FindById(id int) (*WorkflowStatusDlqEntry, error)
+	MarkReplayed(id int) error
+}
+
+type WorkflowStatusDlqRepositoryImpl struct {
+	dbConnection *pg.DB
+}
+
+func NewWorkflowStatusDlqRepositoryImpl(dbConnection *pg.DB) *WorkflowStatusDlqRepositoryImpl {
+	return &WorkflowStatusDlqRepositoryImpl{dbConnection: dbConnection}
+}
+
+func (impl *WorkflowStatusDlqRepositoryImpl) Save(entry *WorkflowStatusDlqEntry) error {
+	return impl.dbConnection.Insert(entry)
+}
+
+func (impl *WorkflowStatusDlqRepositoryImpl) FindAll() ([]*WorkflowStatusDlqEntry, error) {
+	var entries []*WorkflowStatusDlqEntry
+	err := impl.dbConnection.Model(&entries).Order("first_seen DESC").Select()
+	return entries, err
+}
+
+func (impl *WorkflowStatusDlqRepositoryImpl) FindById(id int) (*WorkflowStatusDlqEntry, error) {
+	entry := &WorkflowStatusDlqEntry{Id: id}
+	err := impl.dbConnection.Model(entry).WherePK().Select()
+	return entry, err
+}
+
+func (impl *WorkflowStatusDlqRepositoryImpl) MarkReplayed(id int) error {
+	entry := &WorkflowStatusDlqEntry{Id: id, Replayed: true, ReplayedOn: time.Now()}
+	_, err := impl.dbConnection.Model(entry).WherePK().Column("replayed", "replayed_on").Update()
+	return err
+}
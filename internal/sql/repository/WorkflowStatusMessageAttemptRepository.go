@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package repository
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+// WorkflowStatusMessageAttempt tracks how many times a STAN workflow status message has been
+// handled so a poison message can be bounded-retried and, past the threshold, diverted to a
+// dead-letter subject instead of being retried forever or silently Acked and dropped.
+type WorkflowStatusMessageAttempt struct {
+	tableName struct{}  `sql:"wf_status_message_attempts" pg:",discard_unknown_columns"`
+	Id        int       `sql:"id,pk"`
+	Subject   string    `sql:"subject,notnull"`
+	MsgKey    string    `sql:"msg_key,notnull"` // the message's broker "sequence" attribute, unique within Subject
+	Attempts  int       `sql:"attempts,notnull"`
+	LastError string    `sql:"last_error"`
+	FirstSeen time.Time `sql:"first_seen,notnull"`
+	UpdatedOn time.Time `sql:"updated_on,notnull"`
+}
+
+type WorkflowStatusMessageAttemptRepository interface {
+	FindByMsgKey(subject string, msgKey string) (*WorkflowStatusMessageAttempt, error)
+	Save(attempt *WorkflowStatusMessageAttempt) error
+	Delete(subject string, msgKey string) error
+}
+
+type WorkflowStatusMessageAttemptRepositoryImpl struct {
+	dbConnection *pg.DB
+}
+
+func NewWorkflowStatusMessageAttemptRepositoryImpl(dbConnection *pg.DB) *WorkflowStatusMessageAttemptRepositoryImpl {
+	return &WorkflowStatusMessageAttemptRepositoryImpl{dbConnection: dbConnection}
+}
+
+func (impl *WorkflowStatusMessageAttemptRepositoryImpl) FindByMsgKey(subject string, msgKey string) (*WorkflowStatusMessageAttempt, error) {
+	attempt := &WorkflowStatusMessageAttempt{}
+	err := impl.dbConnection.Model(attempt).
+		Where("subject = ?", subject).
+		Where("msg_key = ?", msgKey).
+		Select()
+	return attempt, err
+}
+
+func (impl *WorkflowStatusMessageAttemptRepositoryImpl) Save(attempt *WorkflowStatusMessageAttempt) error {
+	_, err := impl.dbConnection.Model(attempt).
+		OnConflict("(subject, msg_key) DO UPDATE").
+		Set("attempts = EXCLUDED.attempts, last_error = EXCLUDED.last_error, updated_on = EXCLUDED.updated_on").
+		Insert()
+	return err
+}
+
+func (impl *WorkflowStatusMessageAttemptRepositoryImpl) Delete(subject string, msgKey string) error {
+	_, err := impl.dbConnection.Model(&WorkflowStatusMessageAttempt{}).
+		Where("subject = ?", subject).
+		Where("msg_key = ?", msgKey).
+		Delete()
+	return err
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package pipelineConfig
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+// CdWorkflowScmMeta is the SCM trigger context (repo/branch/PR ref, commit SHA, and the
+// originating SCM-event timestamp) for a CdWorkflow that was triggered by a webhook event
+// (as opposed to a manual trigger). It is captured once, at workflow creation, and read back
+// in SubscribeCD so downstream notifiers can render "deploy for PR #123 @ abc1234 succeeded".
+type CdWorkflowScmMeta struct {
+	tableName    struct{}  `sql:"cd_workflow_scm_meta" pg:",discard_unknown_columns"`
+	Id           int       `sql:"id,pk"`
+	CdWorkflowId int       `sql:"cd_workflow_id,notnull"`
+	RepoUrl      string    `sql:"repo_url"`
+	Ref          string    `sql:"ref"` // branch name, or "refs/pull/123/head" for a PR
+	CommitSha    string    `sql:"commit_sha"`
+	ScmEventAt   time.Time `sql:"scm_event_at"`
+}
+
+type CdWorkflowScmMetaRepository interface {
+	Save(meta *CdWorkflowScmMeta) error
+	FindByCdWorkflowId(cdWorkflowId int) (*CdWorkflowScmMeta, error)
+}
+
+type CdWorkflowScmMetaRepositoryImpl struct {
+	dbConnection *pg.DB
+}
+
+func NewCdWorkflowScmMetaRepositoryImpl(dbConnection *pg.DB) *CdWorkflowScmMetaRepositoryImpl {
+	return &CdWorkflowScmMetaRepositoryImpl{dbConnection: dbConnection}
+}
+
+func (impl *CdWorkflowScmMetaRepositoryImpl) Save(meta *CdWorkflowScmMeta) error {
+	return impl.dbConnection.Insert(meta)
+}
+
+func (impl *CdWorkflowScmMetaRepositoryImpl) FindByCdWorkflowId(cdWorkflowId int) (*CdWorkflowScmMeta, error) {
+	meta := &CdWorkflowScmMeta{}
+	err := impl.dbConnection.Model(meta).
+		Where("cd_workflow_id = ?", cdWorkflowId).
+		Select()
+	return meta, err
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package pipeline
+
+import (
+	"time"
+
+	"github.com/devtron-labs/devtron/internal/sql/repository/pipelineConfig"
+	"go.uber.org/zap"
+)
+
+// CdWorkflowScmMetaService persists the SCM trigger context (repo/ref, commit SHA, SCM-event
+// timestamp) for a CdWorkflow at the moment it's created, so SubscribeCD can read it back later to
+// build "deploy for PR #123 @ abc1234 succeeded" notifications and apply the
+// (pipelineId, workflowType, pr-updated-at) de-dup guard.
+//
+// CaptureTriggerInfo has no caller in this tree yet: the CD trigger flow that creates the
+// CdWorkflow/CdWorkflowRunner rows this service attaches metadata to isn't part of this package
+// set (those types are only ever referenced here via pipelineConfig.CdWorkflowRepository, never
+// defined). Wiring this in requires a change to that trigger flow directly, calling
+// CaptureTriggerInfo with the webhook's repo/ref/commit/event-time right after it inserts the new
+// CdWorkflow row and before handing the run off to Argo. Until that lands, scmTriggerInfoForCdWorkflow
+// always reads back nil/zero, so the PR-aware notification text and the de-dup guard are
+// effectively inert rather than broken: they just have nothing to de-dup against yet.
+type CdWorkflowScmMetaService interface {
+	CaptureTriggerInfo(cdWorkflowId int, repoUrl string, ref string, commitSha string, scmEventAt time.Time) error
+}
+
+type CdWorkflowScmMetaServiceImpl struct {
+	logger                      *zap.SugaredLogger
+	cdWorkflowScmMetaRepository pipelineConfig.CdWorkflowScmMetaRepository
+}
+
+func NewCdWorkflowScmMetaServiceImpl(logger *zap.SugaredLogger, cdWorkflowScmMetaRepository pipelineConfig.CdWorkflowScmMetaRepository) *CdWorkflowScmMetaServiceImpl {
+	return &CdWorkflowScmMetaServiceImpl{
+		logger:                      logger,
+		cdWorkflowScmMetaRepository: cdWorkflowScmMetaRepository,
+	}
+}
+
+func (impl *CdWorkflowScmMetaServiceImpl) CaptureTriggerInfo(cdWorkflowId int, repoUrl string, ref string, commitSha string, scmEventAt time.Time) error {
+	if commitSha == "" {
+		return nil
+	}
+	meta := &pipelineConfig.CdWorkflowScmMeta{
+		CdWorkflowId: cdWorkflowId,
+		RepoUrl:      repoUrl,
+		Ref:          ref,
+		CommitSha:    commitSha,
+		ScmEventAt:   scmEventAt,
+	}
+	if err := impl.cdWorkflowScmMetaRepository.Save(meta); err != nil {
+		impl.logger.Errorw("error saving cd workflow scm meta", "cdWorkflowId", cdWorkflowId, "err", err)
+		return err
+	}
+	return nil
+}
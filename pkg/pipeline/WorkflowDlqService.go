@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/devtron-labs/devtron/internal/sql/repository"
+	"github.com/devtron-labs/devtron/pkg/pubsub/broker"
+	"go.uber.org/zap"
+)
+
+// WorkflowDlqService lists, inspects, and replays workflow status messages that exhausted their
+// bounded retry budget and were diverted to a dead-letter subject.
+type WorkflowDlqService interface {
+	ListDlqEntries() ([]*repository.WorkflowStatusDlqEntry, error)
+	GetDlqEntry(id int) (*repository.WorkflowStatusDlqEntry, error)
+	ReplayDlqEntry(id int) error
+}
+
+type WorkflowDlqServiceImpl struct {
+	logger        *zap.SugaredLogger
+	dlqRepository repository.WorkflowStatusDlqRepository
+	publisher     broker.Publisher
+}
+
+func NewWorkflowDlqServiceImpl(logger *zap.SugaredLogger, dlqRepository repository.WorkflowStatusDlqRepository, publisher broker.Publisher) *WorkflowDlqServiceImpl {
+	return &WorkflowDlqServiceImpl{
+		logger:        logger,
+		dlqRepository: dlqRepository,
+		publisher:     publisher,
+	}
+}
+
+func (impl *WorkflowDlqServiceImpl) ListDlqEntries() ([]*repository.WorkflowStatusDlqEntry, error) {
+	return impl.dlqRepository.FindAll()
+}
+
+func (impl *WorkflowDlqServiceImpl) GetDlqEntry(id int) (*repository.WorkflowStatusDlqEntry, error) {
+	return impl.dlqRepository.FindById(id)
+}
+
+// ReplayDlqEntry republishes the original payload onto its original subject, so it is handled
+// exactly as a fresh delivery would be, then marks the entry as replayed.
+func (impl *WorkflowDlqServiceImpl) ReplayDlqEntry(id int) error {
+	entry, err := impl.dlqRepository.FindById(id)
+	if err != nil {
+		impl.logger.Errorw("could not find dlq entry", "id", id, "err", err)
+		return err
+	}
+	err = impl.publisher.Publish(entry.OriginalSubject, []byte(entry.Payload))
+	if err != nil {
+		impl.logger.Errorw("error replaying dlq entry", "id", id, "err", err)
+		return fmt.Errorf("error replaying dlq entry %d: %w", id, err)
+	}
+	return impl.dlqRepository.MarkReplayed(id)
+}
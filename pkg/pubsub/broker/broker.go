@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package broker abstracts the message queue backend used for workflow status notifications
+// (and anything else built on top of it) behind Publisher/Subscriber interfaces, so Devtron isn't
+// hard-bound to NATS Streaming. Each backend (STAN, NATS JetStream, Kafka, Google Pub/Sub) is
+// registered here and selected at deploy time via BrokerConfig, mirroring the receive-adapter /
+// publisher pattern used elsewhere for pluggable integrations.
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Message is a single delivered message, backend-agnostic. Ack/Nack are no-ops for backends
+// that don't need them (e.g. STAN's Nack, since STAN has no explicit nack), but every adapter
+// implements both so callers never need a type switch.
+type Message interface {
+	Data() []byte
+	Attributes() map[string]string
+	Ack() error
+	Nack() error
+}
+
+// Subscription is a live subscription handle. IsValid reports whether the subscription is still
+// considered healthy by the backend (used by the subscription supervisor to detect silent drops).
+type Subscription interface {
+	IsValid() bool
+	Unsubscribe() error
+}
+
+// SubscriptionConfig carries the durable-name/queue-group/ack-wait knobs that used to be
+// individual stan.SubscriptionOption values sprinkled across call sites. Not every field applies
+// to every backend (e.g. Kafka ignores DurableName, MaxInflight and StartWithLastReceived, and
+// uses AckWait only as its in-process retry backoff); adapters ignore fields they don't use.
+type SubscriptionConfig struct {
+	DurableName           string
+	QueueGroup            string
+	AckWait               time.Duration
+	MaxInflight           int
+	StartWithLastReceived bool
+}
+
+// Publisher publishes raw bytes to a subject/topic.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// Subscriber subscribes to a subject/topic with the given config, invoking handler for each
+// delivered message.
+type Subscriber interface {
+	Subscribe(subject string, cfg SubscriptionConfig, handler func(Message)) (Subscription, error)
+}
+
+// Broker is the full pluggable backend: publish and subscribe.
+type Broker interface {
+	Publisher
+	Subscriber
+	Name() string
+}
+
+// BrokerType selects which Broker implementation NewBroker constructs.
+type BrokerType string
+
+const (
+	BrokerTypeStan         BrokerType = "STAN"
+	BrokerTypeJetStream    BrokerType = "JETSTREAM"
+	BrokerTypeKafka        BrokerType = "KAFKA"
+	BrokerTypeGooglePubSub BrokerType = "GOOGLE_PUBSUB"
+)
+
+// BrokerConfig is the operator-facing config for picking and configuring a broker, read from env
+// the same way the rest of Devtron's client configs are.
+type BrokerConfig struct {
+	Type BrokerType `env:"PUBSUB_BROKER_TYPE" envDefault:"STAN"`
+}
+
+var ErrUnknownBrokerType = errors.New("unknown pubsub broker type")
+
+func newUnknownBrokerTypeError(brokerType BrokerType) error {
+	return fmt.Errorf("%w: %s", ErrUnknownBrokerType, brokerType)
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package broker
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The real adapters (STAN, JetStream, Kafka, Google Pub/Sub) each need a live backend to
+// exercise. fakeBroker is an in-memory Broker that runs the same handler-facing contract
+// (runBrokerContract, below) without one, for fast unit-level coverage of the contract itself;
+// stan_broker_integration_test.go runs the identical contract against a real, embedded STAN
+// server, gated behind the "integration" build tag since it needs to start a real server process.
+var (
+	_ Broker = (*StanBroker)(nil)
+	_ Broker = (*JetStreamBroker)(nil)
+	_ Broker = (*KafkaBroker)(nil)
+	_ Broker = (*GooglePubSubBroker)(nil)
+)
+
+// fakeMessage is the fakeBroker's Message implementation. Ack/Nack just record that they were
+// called, mirroring how a real caller (e.g. messageRetryGuard) would use them.
+type fakeMessage struct {
+	data     []byte
+	sequence int
+	acked    int32
+	nacked   int32
+}
+
+func (m *fakeMessage) Data() []byte { return m.data }
+
+func (m *fakeMessage) Attributes() map[string]string {
+	return map[string]string{"sequence": strconv.Itoa(m.sequence)}
+}
+
+func (m *fakeMessage) Ack() error  { atomic.StoreInt32(&m.acked, 1); return nil }
+func (m *fakeMessage) Nack() error { atomic.StoreInt32(&m.nacked, 1); return nil }
+
+// fakeSubscription tracks liveness the same way the real adapters do: a flag flipped to false on
+// Unsubscribe, readable via IsValid.
+type fakeSubscription struct {
+	alive int32
+}
+
+func (s *fakeSubscription) IsValid() bool { return atomic.LoadInt32(&s.alive) == 1 }
+
+func (s *fakeSubscription) Unsubscribe() error {
+	atomic.StoreInt32(&s.alive, 0)
+	return nil
+}
+
+// fakeBroker delivers every Publish synchronously to every handler currently Subscribed on the
+// same subject, assigning each message an increasing per-subject sequence number.
+type fakeBroker struct {
+	mu       sync.Mutex
+	seq      map[string]int
+	handlers map[string][]func(Message)
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{
+		seq:      make(map[string]int),
+		handlers: make(map[string][]func(Message)),
+	}
+}
+
+func (b *fakeBroker) Name() string { return "fake" }
+
+func (b *fakeBroker) Publish(subject string, data []byte) error {
+	b.mu.Lock()
+	b.seq[subject]++
+	seq := b.seq[subject]
+	handlers := append([]func(Message){}, b.handlers[subject]...)
+	b.mu.Unlock()
+	for _, h := range handlers {
+		h(&fakeMessage{data: data, sequence: seq})
+	}
+	return nil
+}
+
+func (b *fakeBroker) Subscribe(subject string, _ SubscriptionConfig, handler func(Message)) (Subscription, error) {
+	b.mu.Lock()
+	b.handlers[subject] = append(b.handlers[subject], handler)
+	b.mu.Unlock()
+	return &fakeSubscription{alive: 1}, nil
+}
+
+var _ Broker = (*fakeBroker)(nil)
+
+// runBrokerContract exercises the handler-facing contract every Broker backend must satisfy,
+// regardless of transport: a published message reaches a subscribed handler with its bytes
+// intact and a non-empty "sequence" attribute, and Unsubscribe makes the subscription report
+// itself no longer valid.
+func runBrokerContract(t *testing.T, b Broker, cfg SubscriptionConfig) {
+	t.Helper()
+
+	received := make(chan Message, 1)
+	sub, err := b.Subscribe("TEST_SUBJECT", cfg, func(msg Message) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := b.Publish("TEST_SUBJECT", []byte("payload")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if string(msg.Data()) != "payload" {
+			t.Errorf("Data() = %q, want %q", msg.Data(), "payload")
+		}
+		if msg.Attributes()["sequence"] == "" {
+			t.Errorf("Attributes()[\"sequence\"] is empty, want a value unique per message")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler was not invoked within 1s of Publish")
+	}
+
+	if !sub.IsValid() {
+		t.Fatal("IsValid() = false right after Subscribe, want true")
+	}
+	if err := sub.Unsubscribe(); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	if sub.IsValid() {
+		t.Fatal("IsValid() = true after Unsubscribe, want false")
+	}
+}
+
+func TestFakeBrokerContract(t *testing.T) {
+	runBrokerContract(t, newFakeBroker(), SubscriptionConfig{DurableName: "TEST_SUBJECT_DURABLE", QueueGroup: "TEST_SUBJECT_GROUP"})
+}
+
+func TestFakeBrokerSequenceIsUniquePerMessage(t *testing.T) {
+	b := newFakeBroker()
+	var mu sync.Mutex
+	var sequences []string
+	if _, err := b.Subscribe("TEST_SUBJECT", SubscriptionConfig{}, func(msg Message) {
+		mu.Lock()
+		sequences = append(sequences, msg.Attributes()["sequence"])
+		mu.Unlock()
+	}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := b.Publish("TEST_SUBJECT", []byte("payload")); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	seen := make(map[string]bool, len(sequences))
+	for _, s := range sequences {
+		if seen[s] {
+			t.Fatalf("sequence %q delivered more than once: %v", s, sequences)
+		}
+		seen[s] = true
+	}
+	if len(sequences) != 3 {
+		t.Fatalf("got %d deliveries, want 3", len(sequences))
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package broker
+
+import (
+	gcppubsub "cloud.google.com/go/pubsub"
+	devtronPubsub "github.com/devtron-labs/devtron/client/pubsub"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// Deps bundles every backend-specific client NewBroker might need. Only the client matching
+// cfg.Type is actually read; the rest may be nil. Wire supplies whichever of these are configured
+// for the deployment.
+type Deps struct {
+	StanClient         *devtronPubsub.PubSubClient
+	NatsConn           *nats.Conn
+	KafkaBrokers       []string
+	GooglePubSubClient *gcppubsub.Client
+}
+
+// NewBroker constructs the Broker selected by cfg.Type, so the rest of the application depends
+// only on the Broker interface and operators can switch backends at deploy time via config,
+// without a code change.
+func NewBroker(logger *zap.SugaredLogger, cfg BrokerConfig, deps Deps) (Broker, error) {
+	switch cfg.Type {
+	case BrokerTypeStan, "":
+		return NewStanBroker(logger, deps.StanClient), nil
+	case BrokerTypeJetStream:
+		return NewJetStreamBroker(logger, deps.NatsConn)
+	case BrokerTypeKafka:
+		return NewKafkaBroker(logger, deps.KafkaBrokers), nil
+	case BrokerTypeGooglePubSub:
+		return NewGooglePubSubBroker(logger, deps.GooglePubSubClient), nil
+	default:
+		return nil, newUnknownBrokerTypeError(cfg.Type)
+	}
+}
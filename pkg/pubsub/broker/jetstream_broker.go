@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package broker
+
+import (
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// jetStreamMessage adapts a *nats.Msg (delivered with JetStream's manual-ack mode) to Message.
+type jetStreamMessage struct {
+	msg *nats.Msg
+}
+
+func (m *jetStreamMessage) Data() []byte { return m.msg.Data }
+
+func (m *jetStreamMessage) Attributes() map[string]string {
+	attrs := map[string]string{}
+	if meta, err := m.msg.Metadata(); err == nil {
+		attrs["sequence"] = strconv.FormatUint(meta.Sequence.Stream, 10)
+	}
+	return attrs
+}
+
+func (m *jetStreamMessage) Ack() error  { return m.msg.Ack() }
+func (m *jetStreamMessage) Nack() error { return m.msg.Nak() }
+
+// jetStreamSubscription adapts *nats.Subscription to Subscription.
+type jetStreamSubscription struct {
+	sub *nats.Subscription
+}
+
+func (s *jetStreamSubscription) IsValid() bool      { return s.sub.IsValid() }
+func (s *jetStreamSubscription) Unsubscribe() error { return s.sub.Unsubscribe() }
+
+// JetStreamBroker is the Broker implementation backed by NATS JetStream, the supported successor
+// to NATS Streaming.
+type JetStreamBroker struct {
+	logger *zap.SugaredLogger
+	nc     *nats.Conn
+	js     nats.JetStreamContext
+}
+
+func NewJetStreamBroker(logger *zap.SugaredLogger, nc *nats.Conn) (*JetStreamBroker, error) {
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	return &JetStreamBroker{logger: logger, nc: nc, js: js}, nil
+}
+
+func (b *JetStreamBroker) Name() string {
+	return string(BrokerTypeJetStream)
+}
+
+func (b *JetStreamBroker) Publish(subject string, data []byte) error {
+	_, err := b.js.Publish(subject, data)
+	return err
+}
+
+func (b *JetStreamBroker) Subscribe(subject string, cfg SubscriptionConfig, handler func(Message)) (Subscription, error) {
+	opts := []nats.SubOpt{
+		nats.Durable(cfg.DurableName),
+		nats.ManualAck(),
+		nats.AckWait(cfg.AckWait),
+	}
+	if cfg.StartWithLastReceived {
+		opts = append(opts, nats.DeliverLast())
+	}
+	if cfg.MaxInflight > 0 {
+		opts = append(opts, nats.MaxAckPending(cfg.MaxInflight))
+	}
+	sub, err := b.js.QueueSubscribe(subject, cfg.QueueGroup, func(msg *nats.Msg) {
+		handler(&jetStreamMessage{msg: msg})
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &jetStreamSubscription{sub: sub}, nil
+}
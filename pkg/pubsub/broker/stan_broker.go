@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package broker
+
+import (
+	"strconv"
+
+	devtronPubsub "github.com/devtron-labs/devtron/client/pubsub"
+	"github.com/nats-io/stan"
+	"go.uber.org/zap"
+)
+
+// stanMessage adapts a *stan.Msg to the backend-agnostic Message interface.
+type stanMessage struct {
+	msg *stan.Msg
+}
+
+func (m *stanMessage) Data() []byte { return m.msg.Data }
+
+func (m *stanMessage) Attributes() map[string]string {
+	return map[string]string{"sequence": strconv.FormatUint(m.msg.Sequence, 10)}
+}
+
+func (m *stanMessage) Ack() error  { return m.msg.Ack() }
+func (m *stanMessage) Nack() error { return nil } // STAN has no explicit nack; leaving unacked triggers redelivery after AckWait.
+
+// stanSubscription adapts stan.Subscription to Subscription.
+type stanSubscription struct {
+	sub stan.Subscription
+}
+
+func (s *stanSubscription) IsValid() bool      { return s.sub.IsValid() }
+func (s *stanSubscription) Unsubscribe() error { return s.sub.Unsubscribe() }
+
+// StanBroker is the Broker implementation backed by NATS Streaming (STAN), Devtron's original and
+// still-default pubsub backend.
+type StanBroker struct {
+	logger       *zap.SugaredLogger
+	pubsubClient *devtronPubsub.PubSubClient
+}
+
+func NewStanBroker(logger *zap.SugaredLogger, pubsubClient *devtronPubsub.PubSubClient) *StanBroker {
+	return &StanBroker{logger: logger, pubsubClient: pubsubClient}
+}
+
+func (b *StanBroker) Name() string {
+	return string(BrokerTypeStan)
+}
+
+func (b *StanBroker) Publish(subject string, data []byte) error {
+	return b.pubsubClient.Conn.Publish(subject, data)
+}
+
+func (b *StanBroker) Subscribe(subject string, cfg SubscriptionConfig, handler func(Message)) (Subscription, error) {
+	opts := []stan.SubscriptionOption{
+		stan.DurableName(cfg.DurableName),
+		stan.AckWait(cfg.AckWait),
+		stan.SetManualAckMode(),
+	}
+	if cfg.StartWithLastReceived {
+		opts = append(opts, stan.StartWithLastReceived())
+	}
+	if cfg.MaxInflight > 0 {
+		opts = append(opts, stan.MaxInflight(cfg.MaxInflight))
+	}
+	sub, err := b.pubsubClient.Conn.QueueSubscribe(subject, cfg.QueueGroup, func(msg *stan.Msg) {
+		handler(&stanMessage{msg: msg})
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &stanSubscription{sub: sub}, nil
+}
@@ -0,0 +1,61 @@
+//go:build integration
+
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package broker
+
+import (
+	"testing"
+	"time"
+
+	devtronPubsub "github.com/devtron-labs/devtron/client/pubsub"
+	stanserver "github.com/nats-io/nats-streaming-server/server"
+	"github.com/nats-io/stan"
+	"go.uber.org/zap"
+)
+
+// TestStanBrokerContract runs the shared handler contract (runBrokerContract, in broker_test.go)
+// against a real, embedded NATS Streaming server rather than fakeBroker, so the Broker
+// abstraction is actually validated against the backend it was modeled on and not just its own
+// mock. It's built behind the "integration" tag, not the default test run, because it needs to
+// start a real server and bind a local port: `go test -tags integration ./pkg/pubsub/broker/...`.
+func TestStanBrokerContract(t *testing.T) {
+	opts := stanserver.GetDefaultOptions()
+	opts.ID = "test-cluster"
+	opts.StoreType = "MEMORY"
+	srv, err := stanserver.RunServerWithOpts(opts, nil)
+	if err != nil {
+		t.Fatalf("starting embedded stan server: %v", err)
+	}
+	defer srv.Shutdown()
+
+	conn, err := stan.Connect(opts.ID, "test-client", stan.NatsURL(srv.ClientURL()))
+	if err != nil {
+		t.Fatalf("connecting to embedded stan server: %v", err)
+	}
+	defer conn.Close()
+
+	b := NewStanBroker(zap.NewNop().Sugar(), &devtronPubsub.PubSubClient{Conn: conn})
+
+	runBrokerContract(t, b, SubscriptionConfig{
+		DurableName: "TEST_SUBJECT_DURABLE",
+		QueueGroup:  "TEST_SUBJECT_GROUP",
+		AckWait:     30 * time.Second,
+		MaxInflight: 1,
+	})
+}